@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// MigrationAsset is one whole input file, embedded verbatim so it can be
+// plugged into github.com/rubenv/sql-migrate's MemoryMigrationSource; Name
+// is the value to use as each migration's Id there.
+type MigrationAsset struct {
+	Name string
+	SQL  string
+}
+
+// TableDDL is the raw SQL that built one table, concatenated from every
+// CREATE TABLE/ALTER TABLE statement seen for it, in the order they
+// appeared on disk.
+type TableDDL struct {
+	Table string
+	SQL   string
+}
+
+// BuildMigrations turns the files a FileSource parsed into MigrationAssets,
+// using each file's base name as the migration Id the way sql-migrate
+// expects.
+func BuildMigrations(files []SourceFile) []MigrationAsset {
+	out := make([]MigrationAsset, 0, len(files))
+	for _, f := range files {
+		out = append(out, MigrationAsset{Name: filepath.Base(f.Name), SQL: f.SQL})
+	}
+	return out
+}
+
+// GroupDDL collapses a FileSource's DDL statements - one per CREATE/ALTER
+// TABLE - into one entry per table, preserving the order each table was
+// first seen in.
+func GroupDDL(stmts []DDLStatement) []TableDDL {
+	var order []string
+	byTable := map[string][]string{}
+	for _, d := range stmts {
+		if _, ok := byTable[d.Table]; !ok {
+			order = append(order, d.Table)
+		}
+		byTable[d.Table] = append(byTable[d.Table], d.SQL)
+	}
+	out := make([]TableDDL, 0, len(order))
+	for _, t := range order {
+		out = append(out, TableDDL{Table: t, SQL: strings.Join(byTable[t], "\n\n")})
+	}
+	return out
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// DBSource builds tables by introspecting a live Postgres database through
+// information_schema, instead of parsing a .sql file. It produces the same
+// []Table model FileSource does, so it feeds the same template execution
+// path.
+type DBSource struct {
+	DSN          string
+	Schema       string
+	TypeResolver *TypeResolver
+}
+
+// Tables implements Source.
+func (d *DBSource) Tables(matchTables map[string]bool) ([]Table, error) {
+	db, err := sql.Open("postgres", d.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %s", d.DSN, err)
+	}
+	defer db.Close()
+
+	schema := d.Schema
+	if schema == "" {
+		schema = "public"
+	}
+
+	rows, err := db.Query(`
+		SELECT table_name, column_name, data_type, is_nullable
+		FROM information_schema.columns
+		WHERE table_schema = $1
+		ORDER BY table_name, ordinal_position`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read information_schema.columns: %s", err)
+	}
+	defer rows.Close()
+
+	byName := map[string]*Table{}
+	var order []string
+	for rows.Next() {
+		var tableName, columnName, dataType, isNullable string
+		if err := rows.Scan(&tableName, &columnName, &dataType, &isNullable); err != nil {
+			return nil, fmt.Errorf("error scanning information_schema.columns: %s", err)
+		}
+		if len(matchTables) > 0 && !matchTables[tableName] {
+			continue
+		}
+
+		tbl, ok := byName[tableName]
+		if !ok {
+			tbl = &Table{Name: tableName, Schema: schema}
+			byName[tableName] = tbl
+			order = append(order, tableName)
+		}
+
+		notNull := isNullable == "NO"
+		info := d.TypeResolver.ResolveName(dataType, notNull)
+		tbl.Columns = append(tbl.Columns, Column{
+			Name:    columnName,
+			Type:    dataType,
+			GoType:  info.GoType,
+			Import:  info.Import,
+			NotNull: notNull,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading information_schema.columns: %s", err)
+	}
+
+	if err := d.loadPrimaryKeys(db, schema, byName); err != nil {
+		return nil, err
+	}
+	if err := d.loadForeignKeys(db, schema, byName); err != nil {
+		return nil, err
+	}
+	if err := d.loadUnique(db, schema, byName); err != nil {
+		return nil, err
+	}
+	if err := d.loadIndexes(db, schema, byName); err != nil {
+		return nil, err
+	}
+
+	tables := make([]Table, 0, len(order))
+	for _, name := range order {
+		tables = append(tables, *byName[name])
+	}
+	return tables, nil
+}
+
+// loadPrimaryKeys fills in PrimaryKey for each table already in byName from
+// information_schema's key_column_usage/table_constraints views.
+func (d *DBSource) loadPrimaryKeys(db *sql.DB, schema string, byName map[string]*Table) error {
+	rows, err := db.Query(`
+		SELECT kcu.table_name, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+		WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = $1
+		ORDER BY kcu.table_name, kcu.ordinal_position`, schema)
+	if err != nil {
+		return fmt.Errorf("unable to read primary key constraints: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, columnName string
+		if err := rows.Scan(&tableName, &columnName); err != nil {
+			return fmt.Errorf("error scanning primary key constraints: %s", err)
+		}
+		if tbl, ok := byName[tableName]; ok {
+			tbl.PrimaryKey = append(tbl.PrimaryKey, columnName)
+		}
+	}
+	return rows.Err()
+}
+
+// loadForeignKeys fills in ForeignKeys for each table already in byName from
+// information_schema's constraint/referential_constraints views.
+func (d *DBSource) loadForeignKeys(db *sql.DB, schema string, byName map[string]*Table) error {
+	rows, err := db.Query(`
+		SELECT tc.table_name, kcu.column_name, ccu.table_name, ccu.column_name, rc.delete_rule
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+		JOIN information_schema.constraint_column_usage ccu
+			ON tc.constraint_name = ccu.constraint_name AND tc.table_schema = ccu.table_schema
+		JOIN information_schema.referential_constraints rc
+			ON tc.constraint_name = rc.constraint_name AND tc.constraint_schema = rc.constraint_schema
+		WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_schema = $1
+		ORDER BY tc.table_name, kcu.ordinal_position`, schema)
+	if err != nil {
+		return fmt.Errorf("unable to read foreign key constraints: %s", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableName, column, refTable, refColumn, deleteRule string
+		if err := rows.Scan(&tableName, &column, &refTable, &refColumn, &deleteRule); err != nil {
+			return fmt.Errorf("error scanning foreign key constraints: %s", err)
+		}
+		if tbl, ok := byName[tableName]; ok {
+			tbl.ForeignKeys = append(tbl.ForeignKeys, ForeignKey{
+				Column:    column,
+				RefTable:  refTable,
+				RefColumn: refColumn,
+				OnDelete:  deleteRule,
+			})
+		}
+	}
+	return rows.Err()
+}
+
+// loadUnique fills in Unique for each table already in byName from
+// information_schema's UNIQUE table constraints, grouping multi-column
+// constraints back together by constraint name.
+func (d *DBSource) loadUnique(db *sql.DB, schema string, byName map[string]*Table) error {
+	rows, err := db.Query(`
+		SELECT tc.table_name, tc.constraint_name, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON tc.constraint_name = kcu.constraint_name AND tc.table_schema = kcu.table_schema
+		WHERE tc.constraint_type = 'UNIQUE' AND tc.table_schema = $1
+		ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position`, schema)
+	if err != nil {
+		return fmt.Errorf("unable to read unique constraints: %s", err)
+	}
+	defer rows.Close()
+
+	var cols []string
+	tableName, constraintName := "", ""
+	flush := func() {
+		if len(cols) == 0 {
+			return
+		}
+		if tbl, ok := byName[tableName]; ok {
+			tbl.Unique = append(tbl.Unique, cols)
+		}
+	}
+	for rows.Next() {
+		var t, c, column string
+		if err := rows.Scan(&t, &c, &column); err != nil {
+			return fmt.Errorf("error scanning unique constraints: %s", err)
+		}
+		if c != constraintName || t != tableName {
+			flush()
+			tableName, constraintName = t, c
+			cols = nil
+		}
+		cols = append(cols, column)
+	}
+	flush()
+	return rows.Err()
+}
+
+// loadIndexes fills in Indexes for each table already in byName from
+// Postgres's pg_index/pg_class catalogs, since information_schema has no
+// view exposing index column lists.
+func (d *DBSource) loadIndexes(db *sql.DB, schema string, byName map[string]*Table) error {
+	rows, err := db.Query(`
+		SELECT t.relname, i.relname, ix.indisunique, a.attname
+		FROM pg_index ix
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord) ON true
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+		WHERE n.nspname = $1
+		ORDER BY t.relname, i.relname, k.ord`, schema)
+	if err != nil {
+		return fmt.Errorf("unable to read pg_index: %s", err)
+	}
+	defer rows.Close()
+
+	byKey := map[[2]string]*Index{}
+	for rows.Next() {
+		var tableName, indexName, column string
+		var unique bool
+		if err := rows.Scan(&tableName, &indexName, &unique, &column); err != nil {
+			return fmt.Errorf("error scanning pg_index: %s", err)
+		}
+		tbl, ok := byName[tableName]
+		if !ok {
+			continue
+		}
+		key := [2]string{tableName, indexName}
+		idx, ok := byKey[key]
+		if !ok {
+			tbl.Indexes = append(tbl.Indexes, Index{Name: indexName, Unique: unique})
+			idx = &tbl.Indexes[len(tbl.Indexes)-1]
+			byKey[key] = idx
+		}
+		idx.Columns = append(idx.Columns, column)
+	}
+	return rows.Err()
+}
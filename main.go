@@ -4,22 +4,28 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
-	"go/format"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"unicode"
 
-	pg_query "github.com/lfittl/pg_query_go"
 	nodes "github.com/lfittl/pg_query_go/nodes"
 )
 
 const gencodeTmpl = `// Code generated by pgsqlconsts; DO NOT EDIT.
 package {{.Package}}
 
+{{if .Queries}}
+import (
+	"context"
+	"database/sql"
+)
+{{end}}
+
 {{range .Tables}}
 // {{GoTitle .Name}} contains constants for the {{.Name}} table
 var {{GoTitle .Name}} = struct{
@@ -29,60 +35,225 @@ var {{GoTitle .Name}} = struct{
 	{{- end}}
 }{"{{.Name}}",{{range .Columns}}"{{.Name}}",{{end}} }
 {{end}}
-`
+{{template "queries" .}}
+` + queriesTmpl
+
+const gencodeModelsTmpl = `// Code generated by pgsqlconsts; DO NOT EDIT.
+package {{.Package}}
+
+import (
+	{{- if .UsesTime}}
+	"time"
+	{{- end}}
+	{{- if or .UsesSQL .Queries}}
+	"database/sql"
+	{{- end}}
+	{{- if .Queries}}
+	"context"
+	{{- end}}
+	{{- if .UsesUUID}}
+	"github.com/google/uuid"
+	{{- end}}
+	{{- if .UsesNullPkg}}
+	"gopkg.in/guregu/null.v4"
+	{{- end}}
+	{{- range .ExtraImports}}
+	"{{.}}"
+	{{- end}}
+)
+
+{{range .Tables}}
+// {{GoTitle .Name}} is the Go model for the {{.Name}} table.
+{{- if .Schema}}
+// Schema: {{.Schema}}
+{{- end}}
+{{- if .PrimaryKey}}
+// Primary key: {{range .PrimaryKey}}{{.}} {{end}}
+{{- end}}
+{{- range .ForeignKeys}}
+// Foreign key: {{.Column}} references {{.RefTable}}({{.RefColumn}}) ON DELETE {{.OnDelete}}
+{{- end}}
+type {{GoTitle .Name}} struct {
+	{{- range .Columns}}
+	{{GoTitle .Name}} {{.GoType}} ` + "`" + `db:"{{.Name}}" json:"{{.Name}}"` + "`" + ` // {{.Type}}
+	{{- end}}
+}
+{{end}}
+{{template "queries" .}}
+` + queriesTmpl
+
+const gencodeBothTmpl = `// Code generated by pgsqlconsts; DO NOT EDIT.
+package {{.Package}}
+
+import (
+	{{- if .UsesTime}}
+	"time"
+	{{- end}}
+	{{- if or .UsesSQL .Queries}}
+	"database/sql"
+	{{- end}}
+	{{- if .Queries}}
+	"context"
+	{{- end}}
+	{{- if .UsesUUID}}
+	"github.com/google/uuid"
+	{{- end}}
+	{{- if .UsesNullPkg}}
+	"gopkg.in/guregu/null.v4"
+	{{- end}}
+	{{- range .ExtraImports}}
+	"{{.}}"
+	{{- end}}
+)
+
+{{range .Tables}}
+// {{GoTitle .Name}} contains constants for the {{.Name}} table
+var {{GoTitle .Name}} = struct{
+	TableName string
+	{{- range .Columns}}
+	{{GoTitle .Name}} string // {{.Type}}
+	{{- end}}
+}{"{{.Name}}",{{range .Columns}}"{{.Name}}",{{end}} }
+
+// {{GoTitle .Name}}Model is the Go model for the {{.Name}} table.
+type {{GoTitle .Name}}Model struct {
+	{{- range .Columns}}
+	{{GoTitle .Name}} {{.GoType}} ` + "`" + `db:"{{.Name}}" json:"{{.Name}}"` + "`" + ` // {{.Type}}
+	{{- end}}
+}
+{{end}}
+{{template "queries" .}}
+` + queriesTmpl
 
 type Table struct {
-	Name    string
-	Columns []Column
+	Name        string
+	Schema      string
+	Columns     []Column
+	PrimaryKey  []string
+	ForeignKeys []ForeignKey
+	Indexes     []Index
+	Unique      [][]string
 }
 type Column struct {
-	Name string
-	Type string
+	Name    string
+	Type    string
+	GoType  string
+	Import  string
+	NotNull bool
 }
 
 type Data struct {
-	Package string
-	Tables  []Table
+	Package      string
+	Tables       []Table
+	Queries      []Query
+	Migrations   []MigrationAsset
+	DDL          []TableDDL
+	UsesTime     bool
+	UsesSQL      bool
+	UsesUUID     bool
+	UsesNullPkg  bool
+	UsesContext  bool
+	ExtraImports []string
+}
+
+// queriesTmpl is shared by gencodeTmpl, gencodeModelsTmpl, and
+// gencodeBothTmpl via {{template "queries" .}}; it emits nothing when
+// Data.Queries is empty, so -queries can be combined with any -mode.
+const queriesTmpl = `
+{{define "queries"}}
+{{if .Queries}}
+// Querier is satisfied by *sql.DB and *sql.Tx.
+type Querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 }
 
+{{range .Queries}}
+const {{GoTitle .Name}}SQL = ` + "`" + `{{.SQL}}` + "`" + `
+{{if ne .Tag "exec"}}
+type {{GoTitle .Name}}Row struct {
+	{{- range .Results}}
+	{{GoTitle .Name}} {{.GoType}}
+	{{- end}}
+}
+{{end}}
+func {{.Name}}(ctx context.Context, db Querier{{range .Params}}, {{.Name}} {{.GoType}}{{end}}) ({{if eq .Tag "one"}}{{GoTitle .Name}}Row{{else if eq .Tag "many"}}[]{{GoTitle .Name}}Row{{else}}sql.Result{{end}}, error) {
+	{{- if eq .Tag "exec"}}
+	return db.ExecContext(ctx, {{GoTitle .Name}}SQL{{range .Params}}, {{.Name}}{{end}})
+	{{- else if eq .Tag "one"}}
+	var row {{GoTitle .Name}}Row
+	err := db.QueryRowContext(ctx, {{GoTitle .Name}}SQL{{range .Params}}, {{.Name}}{{end}}).Scan({{range .Results}}&row.{{GoTitle .Name}}, {{end}})
+	return row, err
+	{{- else}}
+	rows, err := db.QueryContext(ctx, {{GoTitle .Name}}SQL{{range .Params}}, {{.Name}}{{end}})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []{{GoTitle .Name}}Row
+	for rows.Next() {
+		var row {{GoTitle .Name}}Row
+		if err := rows.Scan({{range .Results}}&row.{{GoTitle .Name}}, {{end}}); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+	{{- end}}
+}
+{{end}}
+{{end}}
+{{end}}
+`
+
 func main() {
 	pkg := flag.String("package", "models", "package name")
 	matchTables := flag.String("tables", "", "comma separated list of tables to generate (default all tables)")
 	outputFile := flag.String("output", "", "if specified, file to write generated code to (default stdout)")
 	templateFile := flag.String("template", "", "template file to use for generation")
+	mode := flag.String("mode", "consts", "what to generate: consts, models, or both")
+	typeConfig := flag.String("type-config", "", "JSON file of PostgreSQL type name to Go type overrides")
+	genQueries := flag.Bool("queries", false, "also generate typed Go functions for -- name: X :tag annotated queries")
+	dsn := flag.String("dsn", "", "if specified, introspect this Postgres connection string instead of parsing a SQLFILE")
+	dbSchema := flag.String("schema", "public", "schema to introspect when -dsn is set")
+	embedSQL := flag.Bool("embed-sql", false, "also emit an assets.go embedding the parsed DDL and a Migrations variable for sql-migrate")
 
 	flag.Parse()
-	if flag.NArg() != 1 {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [OPTION]... [SQLFILE]\n", os.Args[0])
-		flag.PrintDefaults()
-		os.Exit(1)
-	}
-	sqlFile := flag.Arg(0)
 
-	// go read our SQL
-	fc, err := ioutil.ReadFile(sqlFile)
-	if err != nil {
-		log.Fatalf("unable to open %s: %s", sqlFile, err)
+	switch *mode {
+	case "consts", "models", "both":
+	default:
+		log.Fatalf("unknown -mode %q, must be consts, models, or both", *mode)
+	}
+	if *embedSQL && *outputFile == "" {
+		log.Fatalf("-embed-sql requires -output (a single file or directory), since it emits a second file alongside the generated code")
 	}
-	stmt, err := pg_query.Parse(string(fc))
+
+	typeResolver, err := NewTypeResolver(*typeConfig)
 	if err != nil {
-		log.Fatalf("error parsing sql: %s", err)
+		log.Fatalf("%s", err)
 	}
 
-	// walk it, looking for "CREATE TABLE" statements
-	createTables := []nodes.CreateStmt{}
-	for _, n := range stmt.Statements {
-		switch n := n.(type) {
-		case nodes.RawStmt:
-			switch s := n.Stmt.(type) {
-			case nodes.CreateStmt:
-				createTables = append(createTables, s)
-			}
-		case nodes.CreateStmt:
-			createTables = append(createTables, n)
-		default:
-			log.Printf("unexpected statement type %T\n", n)
+	var src Source
+	var fileSrc *FileSource
+	if *dsn != "" {
+		if *genQueries {
+			log.Fatalf("-queries requires a parsed SQLFILE, not -dsn")
+		}
+		src = &DBSource{DSN: *dsn, Schema: *dbSchema, TypeResolver: typeResolver}
+	} else {
+		if flag.NArg() == 0 {
+			fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [OPTION]... SQLFILE...\n", os.Args[0])
+			flag.PrintDefaults()
+			os.Exit(1)
+		}
+		sqlPaths, err := expandSQLPaths(flag.Args())
+		if err != nil {
+			log.Fatalf("%s", err)
 		}
+		fileSrc = &FileSource{Paths: sqlPaths, TypeResolver: typeResolver}
+		src = fileSrc
 	}
 
 	tables := map[string]bool{}
@@ -96,29 +267,27 @@ func main() {
 	data := Data{
 		Package: *pkg,
 	}
-	for _, s := range createTables {
-		tableName := *s.Relation.Relname
-		if len(tables) > 0 && !tables[tableName] {
-			continue
-		}
+	data.Tables, err = src.Tables(tables)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
 
-		tbl := Table{
-			Name: tableName,
+	if *genQueries {
+		queries, err := ParseQueries(fileSrc.Files, data.Tables)
+		if err != nil {
+			log.Fatalf("%s", err)
 		}
-		for _, col := range s.TableElts.Items {
-			cd, ok := col.(nodes.ColumnDef)
-			if !ok {
-				continue
-			}
-			tbl.Columns = append(tbl.Columns,
-				Column{
-					Name: *cd.Colname,
-					Type: toString(cd.TypeName.Names.Items),
-				})
-
+		data.Queries = queries
+		data.UsesContext = true
+	}
+	if *embedSQL {
+		if fileSrc == nil {
+			log.Fatalf("-embed-sql requires a parsed SQLFILE, not -dsn")
 		}
-		data.Tables = append(data.Tables, tbl)
+		data.Migrations = BuildMigrations(fileSrc.Files)
+		data.DDL = GroupDDL(fileSrc.DDL)
 	}
+	recordImports(&data)
 
 	fmap := template.FuncMap{}
 	fmap["Title"] = strings.Title
@@ -127,6 +296,12 @@ func main() {
 	fmap["ToLower"] = strings.ToLower
 
 	templateText := gencodeTmpl
+	switch *mode {
+	case "models":
+		templateText = gencodeModelsTmpl
+	case "both":
+		templateText = gencodeBothTmpl
+	}
 	if *templateFile != "" {
 		d, err := ioutil.ReadFile(*templateFile)
 		if err != nil {
@@ -135,30 +310,49 @@ func main() {
 		templateText = string(d)
 	}
 
-	tmpl, err := template.New("").Funcs(fmap).Parse(templateText)
-	if err != nil {
-		log.Fatalf("unable to parse template: %s", err)
+	if *templateFile == "" && isOutputDir(*outputFile) {
+		if err := writeMultiFile(*outputFile, templateText, fmap, data); err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
 	}
-	buf := bytes.Buffer{}
-	if err := tmpl.Execute(&buf, data); err != nil {
-		log.Fatalf("error executing template: %s", err)
+	if err := writeSingleFile(templateText, fmap, data, *outputFile); err != nil {
+		log.Fatalf("%s", err)
 	}
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		io.Copy(os.Stderr, &buf)
-		log.Fatalf("generated bad code: %s", err)
+	if *embedSQL {
+		assetsPath := ""
+		if *outputFile != "" {
+			assetsPath = filepath.Join(filepath.Dir(*outputFile), "assets.go")
+		}
+		if err := writeAssets(fmap, data, assetsPath); err != nil {
+			log.Fatalf("%s", err)
+		}
 	}
+}
 
-	var w io.Writer = os.Stdout
-	if *outputFile != "" {
-		f, err := os.Create(*outputFile)
+// expandSQLPaths turns the positional SQLFILE arguments into a sorted,
+// deduplicated list of paths, expanding any glob patterns among them (e.g.
+// "migrations/*.sql") so multiple input files can be passed at once.
+func expandSQLPaths(args []string) ([]string, error) {
+	seen := map[string]bool{}
+	var paths []string
+	for _, a := range args {
+		matches, err := filepath.Glob(a)
 		if err != nil {
-			log.Fatalf("error creating output file: %s", err)
+			return nil, fmt.Errorf("invalid glob pattern %q: %s", a, err)
+		}
+		if len(matches) == 0 {
+			matches = []string{a}
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				paths = append(paths, m)
+			}
 		}
-		w = f
-		defer f.Close()
 	}
-	io.Copy(w, bytes.NewReader(formatted))
+	sort.Strings(paths)
+	return paths, nil
 }
 
 func goTitleCase(s string) string {
@@ -187,6 +381,71 @@ func goTitleCase(s string) string {
 	return buf.String()
 }
 
+// isNotNull reports whether a column's constraint list includes NOT NULL or
+// PRIMARY KEY, either of which makes the column non-nullable.
+func isNotNull(constraints []nodes.Node) bool {
+	for _, c := range constraints {
+		con, ok := c.(nodes.Constraint)
+		if !ok {
+			continue
+		}
+		switch con.Contype {
+		case nodes.CONSTR_NOTNULL, nodes.CONSTR_PRIMARY:
+			return true
+		}
+	}
+	return false
+}
+
+// recordImports scans every resolved Go type in data - table columns and
+// query params/results alike - to decide which package-level imports the
+// template's import block needs. The handful of types this tool ships
+// mappings for are recognized by their Go type prefix; anything else (a
+// -type-config override naming its own package) is picked up from
+// Column.Import/QueryParam.Import and collected into ExtraImports.
+func recordImports(data *Data) {
+	for _, t := range data.Tables {
+		for _, c := range t.Columns {
+			scanGoType(data, c.GoType, c.Import)
+		}
+	}
+	for _, q := range data.Queries {
+		for _, p := range q.Params {
+			scanGoType(data, p.GoType, p.Import)
+		}
+		for _, r := range q.Results {
+			scanGoType(data, r.GoType, r.Import)
+		}
+	}
+	sort.Strings(data.ExtraImports)
+}
+
+func scanGoType(data *Data, goType, importPath string) {
+	switch {
+	case goType == "time.Time":
+		data.UsesTime = true
+		return
+	case strings.HasPrefix(goType, "sql.Null"):
+		data.UsesSQL = true
+		return
+	case strings.HasPrefix(goType, "uuid."):
+		data.UsesUUID = true
+		return
+	case strings.HasPrefix(goType, "null."):
+		data.UsesNullPkg = true
+		return
+	}
+	if importPath == "" {
+		return
+	}
+	for _, existing := range data.ExtraImports {
+		if existing == importPath {
+			return
+		}
+	}
+	data.ExtraImports = append(data.ExtraImports, importPath)
+}
+
 func toString(nod []nodes.Node) string {
 	buf := bytes.Buffer{}
 	for _, n := range nod {
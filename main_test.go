@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+// TestRecordImportsCustomOverride covers a -type-config override whose Go
+// type lives in a package this tool has no built-in handling for: the
+// import must make it into Data.ExtraImports, and built-in types must not
+// leak into it (they're already covered by the Uses* flags).
+func TestRecordImportsCustomOverride(t *testing.T) {
+	data := &Data{
+		Tables: []Table{
+			{Columns: []Column{
+				{Name: "price", GoType: "money.Amount", Import: "github.com/acme/money"},
+				{Name: "id", GoType: "int64"},
+				{Name: "created_at", GoType: "time.Time"},
+			}},
+		},
+	}
+	recordImports(data)
+
+	if !data.UsesTime {
+		t.Errorf("expected UsesTime, got false")
+	}
+	if len(data.ExtraImports) != 1 || data.ExtraImports[0] != "github.com/acme/money" {
+		t.Fatalf("ExtraImports = %v, want [github.com/acme/money]", data.ExtraImports)
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	pg_query "github.com/lfittl/pg_query_go"
+	nodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// QueryTag is the sqlc-style return-shape annotation on a
+// `-- name: X :tag` comment: :one returns a single row, :many a slice,
+// :exec no rows at all.
+type QueryTag string
+
+const (
+	QueryOne  QueryTag = "one"
+	QueryMany QueryTag = "many"
+	QueryExec QueryTag = "exec"
+)
+
+// Query describes one annotated SQL statement, resolved against the tables
+// already parsed from CREATE/ALTER TABLE, ready to emit as a typed Go
+// function alongside its SQL string.
+type Query struct {
+	Name    string
+	Tag     QueryTag
+	SQL     string
+	Params  []QueryParam
+	Results []Column
+}
+
+// QueryParam is one `$N` placeholder in a query, in positional order, with
+// the Go type it was inferred to have from the column it's compared or
+// assigned to.
+type QueryParam struct {
+	Name   string
+	GoType string
+	Import string
+}
+
+var queryNameRe = regexp.MustCompile(`(?m)--\s*name:\s*(\w+)\s*:(one|many|exec)\s*$`)
+
+// goKeywords are the reserved words a column/target name can't be emitted
+// as a Go identifier verbatim.
+var goKeywords = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+}
+
+// sanitizeParamName appends an underscore to a name that collides with a Go
+// keyword, so a column like `type` or `func` can still be used as a
+// generated function's parameter name.
+func sanitizeParamName(name string) string {
+	if goKeywords[name] {
+		return name + "_"
+	}
+	return name
+}
+
+// ParseQueries scans each file for `-- name: Foo :one` style annotations
+// (the convention sqlc uses) immediately preceding a statement, and
+// resolves each annotated statement's parameters and result columns
+// against tables, which must already be populated from the CREATE/ALTER
+// TABLE statements across all of files.
+func ParseQueries(files []SourceFile, tables []Table) ([]Query, error) {
+	byName := map[string]*Table{}
+	for i := range tables {
+		byName[tables[i].Name] = &tables[i]
+	}
+
+	var queries []Query
+	for _, f := range files {
+		parsed, err := pg_query.Parse(f.SQL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s for queries: %s", f.Name, err)
+		}
+
+		for _, n := range parsed.Statements {
+			raw, ok := n.(nodes.RawStmt)
+			if !ok {
+				continue
+			}
+			name, tag, ok := annotationAt(f.SQL, raw.StmtLocation)
+			if !ok {
+				continue
+			}
+			q := Query{
+				Name: name,
+				Tag:  QueryTag(tag),
+				SQL:  stmtText(f.SQL, raw),
+			}
+			switch s := raw.Stmt.(type) {
+			case nodes.SelectStmt:
+				resolveSelect(&q, s, byName)
+			case nodes.InsertStmt:
+				resolveInsert(&q, s, byName)
+			case nodes.UpdateStmt:
+				resolveUpdate(&q, s, byName)
+			case nodes.DeleteStmt:
+				resolveDelete(&q, s, byName)
+			default:
+				log.Printf("query %s: unsupported statement type %T, params/results left untyped", name, s)
+			}
+			queries = append(queries, q)
+		}
+	}
+	return queries, nil
+}
+
+// annotationAt looks for the nearest `-- name: X :tag` comment on the lines
+// starting at the byte offset loc, skipping blank lines. loc is
+// RawStmt.StmtLocation, which pg_query_go sets to the position right after
+// the previous statement - i.e. before any comment preceding this one, not
+// after it.
+func annotationAt(src string, loc int) (name, tag string, ok bool) {
+	if loc < 0 {
+		loc = 0
+	}
+	if loc > len(src) {
+		loc = len(src)
+	}
+	lines := strings.Split(src[loc:], "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m := queryNameRe.FindStringSubmatch(line)
+		if m == nil {
+			return "", "", false
+		}
+		return m[1], m[2], true
+	}
+	return "", "", false
+}
+
+// soleTable returns the single table a FROM/UPDATE/DELETE clause targets,
+// which is all this first pass of type inference understands.
+func soleTable(rv *nodes.RangeVar, byName map[string]*Table) *Table {
+	if rv == nil || rv.Relname == nil {
+		return nil
+	}
+	return byName[*rv.Relname]
+}
+
+func resolveSelect(q *Query, s nodes.SelectStmt, byName map[string]*Table) {
+	var tbl *Table
+	if len(s.FromClause.Items) == 1 {
+		if rv, ok := s.FromClause.Items[0].(nodes.RangeVar); ok {
+			tbl = soleTable(&rv, byName)
+		}
+	}
+	if tbl == nil {
+		log.Printf("query %s: could not resolve a single source table, results left untyped", q.Name)
+	} else {
+		for _, t := range s.TargetList.Items {
+			res, ok := t.(nodes.ResTarget)
+			if !ok || res.Val == nil {
+				continue
+			}
+			switch v := res.Val.(type) {
+			case nodes.ColumnRef:
+				if col := resolveColumnRef(v, tbl); col != nil {
+					q.Results = append(q.Results, *col)
+				}
+			default:
+				q.Results = append(q.Results, Column{Name: "col", GoType: "interface{}"})
+			}
+		}
+	}
+	params := map[int]QueryParam{}
+	collectParams(s.WhereClause, tbl, params)
+	q.Params = orderParams(params)
+}
+
+func resolveInsert(q *Query, s nodes.InsertStmt, byName map[string]*Table) {
+	tbl := soleTable(s.Relation, byName)
+	sel, ok := s.SelectStmt.(nodes.SelectStmt)
+	if !ok || len(sel.ValuesLists) == 0 {
+		log.Printf("query %s: INSERT has no VALUES list, params left untyped", q.Name)
+		return
+	}
+	for i, v := range sel.ValuesLists[0] {
+		if _, ok := v.(nodes.ParamRef); !ok {
+			continue
+		}
+		goType := "interface{}"
+		var importPath string
+		if tbl != nil && i < len(s.Cols.Items) {
+			if target, ok := s.Cols.Items[i].(nodes.ResTarget); ok && target.Name != nil {
+				if col := columnByName(tbl, *target.Name); col != nil {
+					goType = col.GoType
+					importPath = col.Import
+				}
+			}
+		}
+		q.Params = append(q.Params, QueryParam{Name: fmt.Sprintf("arg%d", i+1), GoType: goType, Import: importPath})
+	}
+	if tbl != nil {
+		q.Results = tbl.Columns
+	}
+}
+
+func resolveUpdate(q *Query, s nodes.UpdateStmt, byName map[string]*Table) {
+	tbl := soleTable(s.Relation, byName)
+	params := map[int]QueryParam{}
+	for _, t := range s.TargetList.Items {
+		res, ok := t.(nodes.ResTarget)
+		if !ok {
+			continue
+		}
+		paramRef, ok := res.Val.(nodes.ParamRef)
+		if !ok {
+			continue
+		}
+		goType := "interface{}"
+		var importPath string
+		if tbl != nil && res.Name != nil {
+			if col := columnByName(tbl, *res.Name); col != nil {
+				goType = col.GoType
+				importPath = col.Import
+			}
+		}
+		name := "val"
+		if res.Name != nil {
+			name = *res.Name
+		}
+		params[paramRef.Number] = QueryParam{Name: sanitizeParamName(name), GoType: goType, Import: importPath}
+	}
+	collectParams(s.WhereClause, tbl, params)
+	q.Params = orderParams(params)
+}
+
+func resolveDelete(q *Query, s nodes.DeleteStmt, byName map[string]*Table) {
+	tbl := soleTable(s.Relation, byName)
+	params := map[int]QueryParam{}
+	collectParams(s.WhereClause, tbl, params)
+	q.Params = orderParams(params)
+}
+
+// collectParams walks a WHERE clause looking for `column = $N` comparisons
+// (and their AND/OR combinations), recording the Go type of the column each
+// parameter was compared against.
+func collectParams(n nodes.Node, tbl *Table, params map[int]QueryParam) {
+	switch v := n.(type) {
+	case nil:
+	case nodes.BoolExpr:
+		for _, a := range v.Args.Items {
+			collectParams(a, tbl, params)
+		}
+	case nodes.A_Expr:
+		var colRef *nodes.ColumnRef
+		var paramRef *nodes.ParamRef
+		if c, ok := v.Lexpr.(nodes.ColumnRef); ok {
+			colRef = &c
+		}
+		if p, ok := v.Rexpr.(nodes.ParamRef); ok {
+			paramRef = &p
+		}
+		if colRef == nil {
+			if c, ok := v.Rexpr.(nodes.ColumnRef); ok {
+				colRef = &c
+			}
+		}
+		if paramRef == nil {
+			if p, ok := v.Lexpr.(nodes.ParamRef); ok {
+				paramRef = &p
+			}
+		}
+		if paramRef == nil {
+			return
+		}
+		goType := "interface{}"
+		var importPath string
+		name := fmt.Sprintf("p%d", paramRef.Number)
+		if colRef != nil && tbl != nil {
+			if col := resolveColumnRef(*colRef, tbl); col != nil {
+				goType = col.GoType
+				importPath = col.Import
+				name = col.Name
+			}
+		}
+		params[paramRef.Number] = QueryParam{Name: sanitizeParamName(name), GoType: goType, Import: importPath}
+	}
+}
+
+// orderParams turns the paramIndex -> QueryParam map collectParams builds
+// into the positional $1, $2, ... slice a function signature needs.
+func orderParams(params map[int]QueryParam) []QueryParam {
+	if len(params) == 0 {
+		return nil
+	}
+	max := 0
+	for i := range params {
+		if i > max {
+			max = i
+		}
+	}
+	ordered := make([]QueryParam, 0, len(params))
+	for i := 1; i <= max; i++ {
+		p, ok := params[i]
+		if !ok {
+			p = QueryParam{Name: fmt.Sprintf("p%d", i), GoType: "interface{}"}
+		}
+		ordered = append(ordered, p)
+	}
+	return ordered
+}
+
+func resolveColumnRef(ref nodes.ColumnRef, tbl *Table) *Column {
+	if len(ref.Fields.Items) == 0 {
+		return nil
+	}
+	last := ref.Fields.Items[len(ref.Fields.Items)-1]
+	s, ok := last.(nodes.String)
+	if !ok {
+		return nil
+	}
+	return columnByName(tbl, s.Str)
+}
+
+func columnByName(tbl *Table, name string) *Column {
+	if tbl == nil {
+		return nil
+	}
+	for i := range tbl.Columns {
+		if tbl.Columns[i].Name == name {
+			return &tbl.Columns[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseQueriesFindsAnnotations is a round trip over the documented
+// `-- name: X :tag` usage: it would have caught annotationAt looking on the
+// wrong side of StmtLocation and silently finding zero queries.
+func TestParseQueriesFindsAnnotations(t *testing.T) {
+	sql := `CREATE TABLE users (
+	id bigserial PRIMARY KEY,
+	name text NOT NULL
+);
+
+-- name: GetUserByID :one
+SELECT id, name FROM users WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT id, name FROM users;
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.sql")
+	if err := os.WriteFile(path, []byte(sql), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewTypeResolver("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FileSource{Paths: []string{path}, TypeResolver: r}
+	tables, err := fs.Tables(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries, err := ParseQueries(fs.Files, tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 queries, got %d: %+v", len(queries), queries)
+	}
+	if queries[0].Name != "GetUserByID" || queries[0].Tag != QueryOne {
+		t.Fatalf("unexpected query: %+v", queries[0])
+	}
+	if queries[1].Name != "ListUsers" || queries[1].Tag != QueryMany {
+		t.Fatalf("unexpected query: %+v", queries[1])
+	}
+}
+
+// TestParseQueriesSanitizesKeywordParamNames covers a column named `type`
+// used in a WHERE clause, which would otherwise generate an illegal Go
+// function signature (`func GetWidgetByType(..., type string)`).
+func TestParseQueriesSanitizesKeywordParamNames(t *testing.T) {
+	sql := `CREATE TABLE widgets (
+	id bigserial PRIMARY KEY,
+	type text NOT NULL
+);
+
+-- name: GetWidgetByType :one
+SELECT id, type FROM widgets WHERE type = $1;
+`
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.sql")
+	if err := os.WriteFile(path, []byte(sql), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewTypeResolver("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := &FileSource{Paths: []string{path}, TypeResolver: r}
+	tables, err := fs.Tables(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries, err := ParseQueries(fs.Files, tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 || len(queries[0].Params) != 1 {
+		t.Fatalf("unexpected queries: %+v", queries)
+	}
+	if got := queries[0].Params[0].Name; got != "type_" {
+		t.Fatalf("param name = %q, want %q", got, "type_")
+	}
+}
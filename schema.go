@@ -0,0 +1,151 @@
+package main
+
+import (
+	nodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// ForeignKey describes a `REFERENCES` relationship from a column to another
+// table's column, as declared either inline on a ColumnDef or via a
+// table-level / ALTER TABLE ADD CONSTRAINT ... FOREIGN KEY clause.
+type ForeignKey struct {
+	Column    string
+	RefTable  string
+	RefColumn string
+	OnDelete  string
+}
+
+// Index describes a CREATE INDEX statement targeting one of our tables.
+type Index struct {
+	Name    string
+	Columns []string
+	Unique  bool
+}
+
+// onDeleteAction turns libpg_query's single-byte FK action code into the SQL
+// keyword it represents, defaulting to "NO ACTION" like Postgres does.
+func onDeleteAction(action byte) string {
+	switch action {
+	case 'r':
+		return "RESTRICT"
+	case 'c':
+		return "CASCADE"
+	case 'n':
+		return "SET NULL"
+	case 'd':
+		return "SET DEFAULT"
+	default:
+		return "NO ACTION"
+	}
+}
+
+// applyConstraint folds a single Constraint node - whether attached to a
+// ColumnDef, a table-level constraint in CreateStmt, or an
+// ALTER TABLE ... ADD CONSTRAINT - into tbl's primary key, foreign key, and
+// unique sets. column is the column the constraint was declared on, if any
+// (inline constraints only; table-level constraints carry their own Keys).
+func applyConstraint(tbl *Table, con nodes.Constraint, column string) {
+	switch con.Contype {
+	case nodes.CONSTR_PRIMARY:
+		if column != "" {
+			tbl.PrimaryKey = append(tbl.PrimaryKey, column)
+		} else if con.Keys.Items != nil {
+			tbl.PrimaryKey = append(tbl.PrimaryKey, constraintKeys(&con.Keys)...)
+		}
+	case nodes.CONSTR_UNIQUE:
+		if column != "" {
+			tbl.Unique = append(tbl.Unique, []string{column})
+		} else if con.Keys.Items != nil {
+			tbl.Unique = append(tbl.Unique, constraintKeys(&con.Keys))
+		}
+	case nodes.CONSTR_FOREIGN:
+		refTable := ""
+		if con.Pktable != nil {
+			refTable = *con.Pktable.Relname
+		}
+		refColumn := ""
+		if pkAttrs := constraintKeys(&con.PkAttrs); len(pkAttrs) > 0 {
+			refColumn = pkAttrs[0]
+		}
+		fkColumn := column
+		if fkAttrs := constraintKeys(&con.FkAttrs); fkColumn == "" && len(fkAttrs) > 0 {
+			fkColumn = fkAttrs[0]
+		}
+		tbl.ForeignKeys = append(tbl.ForeignKeys, ForeignKey{
+			Column:    fkColumn,
+			RefTable:  refTable,
+			RefColumn: refColumn,
+			OnDelete:  onDeleteAction(con.FkDelAction),
+		})
+	}
+}
+
+// constraintKeys extracts the plain column names out of a Constraint's Keys
+// (or PkAttrs/FkAttrs) list, which libpg_query represents as a List of
+// nodes.String.
+func constraintKeys(keys *nodes.List) []string {
+	if keys == nil {
+		return nil
+	}
+	var names []string
+	for _, k := range keys.Items {
+		if s, ok := k.(nodes.String); ok {
+			names = append(names, s.Str)
+		}
+	}
+	return names
+}
+
+// applyAlterTable folds an AlterTableStmt's ADD COLUMN / ADD CONSTRAINT
+// commands into an already-collected Table, so schema changes that arrive
+// after the original CREATE TABLE still show up in the generated model.
+func applyAlterTable(tbl *Table, stmt nodes.AlterTableStmt, typeResolver *TypeResolver) {
+	for _, c := range stmt.Cmds.Items {
+		cmd, ok := c.(nodes.AlterTableCmd)
+		if !ok {
+			continue
+		}
+		switch cmd.Subtype {
+		case nodes.AT_AddColumn:
+			cd, ok := cmd.Def.(nodes.ColumnDef)
+			if !ok {
+				continue
+			}
+			notNull := isNotNull(cd.Constraints.Items)
+			info := typeResolver.Resolve(cd.TypeName.Names.Items, notNull)
+			tbl.Columns = append(tbl.Columns, Column{
+				Name:    *cd.Colname,
+				Type:    toString(cd.TypeName.Names.Items),
+				GoType:  info.GoType,
+				Import:  info.Import,
+				NotNull: notNull,
+			})
+			for _, cc := range cd.Constraints.Items {
+				if con, ok := cc.(nodes.Constraint); ok {
+					applyConstraint(tbl, con, *cd.Colname)
+				}
+			}
+		case nodes.AT_AddConstraint:
+			con, ok := cmd.Def.(nodes.Constraint)
+			if !ok {
+				continue
+			}
+			applyConstraint(tbl, con, "")
+		}
+	}
+}
+
+// applyIndex records a CREATE INDEX statement against the table it targets.
+func applyIndex(tbl *Table, stmt nodes.IndexStmt) {
+	idx := Index{
+		Name:   *stmt.Idxname,
+		Unique: stmt.Unique,
+	}
+	for _, p := range stmt.IndexParams.Items {
+		elem, ok := p.(nodes.IndexElem)
+		if !ok || elem.Name == nil {
+			continue
+		}
+		idx.Columns = append(idx.Columns, *elem.Name)
+	}
+	tbl.Indexes = append(tbl.Indexes, idx)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	pg_query "github.com/lfittl/pg_query_go"
+	nodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// Source produces the []Table model that feeds the template execution
+// path, regardless of whether the schema came from a parsed .sql file or a
+// live database connection.
+type Source interface {
+	Tables(matchTables map[string]bool) ([]Table, error)
+}
+
+// SourceFile is one input file FileSource parsed, kept around so -queries
+// can re-parse it for annotated statements and -embed-sql can embed it
+// whole as a migration.
+type SourceFile struct {
+	Name string
+	SQL  string
+}
+
+// DDLStatement is the raw SQL text of a single CREATE TABLE or ALTER TABLE
+// statement, captured for -embed-sql.
+type DDLStatement struct {
+	Table string
+	SQL   string
+}
+
+// FileSource builds tables by parsing one or more .sql files with pg_query,
+// folding every file's CREATE TABLE, ALTER TABLE, and CREATE INDEX
+// statements together into one coherent model per table.
+type FileSource struct {
+	Paths        []string
+	TypeResolver *TypeResolver
+
+	// Files and DDL are populated after Tables has run, so callers
+	// (-queries, -embed-sql) can make a second pass over the same input.
+	Files []SourceFile
+	DDL   []DDLStatement
+}
+
+// Tables implements Source.
+func (f *FileSource) Tables(matchTables map[string]bool) ([]Table, error) {
+	createTables := []nodes.CreateStmt{}
+	alterTables := []nodes.AlterTableStmt{}
+	createIndexes := []nodes.IndexStmt{}
+
+	for _, path := range f.Paths {
+		fc, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open %s: %s", path, err)
+		}
+		src := string(fc)
+		f.Files = append(f.Files, SourceFile{Name: path, SQL: src})
+
+		stmt, err := pg_query.Parse(src)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", path, err)
+		}
+
+		// walk it, looking for statements that shape our table model:
+		// CREATE TABLE, CREATE SCHEMA, ALTER TABLE, and CREATE INDEX.
+		for _, n := range stmt.Statements {
+			raw, hasLocation := n.(nodes.RawStmt)
+			inner := n
+			if hasLocation {
+				inner = raw.Stmt
+			}
+			switch s := inner.(type) {
+			case nodes.CreateStmt:
+				createTables = append(createTables, s)
+				if hasLocation {
+					f.DDL = append(f.DDL, DDLStatement{Table: *s.Relation.Relname, SQL: stmtText(src, raw)})
+				}
+			case nodes.CreateSchemaStmt:
+				// schema creation carries no columns of its own; a table created
+				// by a separate CREATE TABLE schema.name statement shows up with
+				// a Schemaname on its own CreateStmt. CREATE TABLE elements
+				// nested inside the CREATE SCHEMA statement itself are not read.
+			case nodes.AlterTableStmt:
+				alterTables = append(alterTables, s)
+				if hasLocation {
+					f.DDL = append(f.DDL, DDLStatement{Table: *s.Relation.Relname, SQL: stmtText(src, raw)})
+				}
+			case nodes.IndexStmt:
+				createIndexes = append(createIndexes, s)
+			default:
+				log.Printf("unexpected statement type %T\n", inner)
+			}
+		}
+	}
+
+	var tables []Table
+	for _, s := range createTables {
+		tableName := *s.Relation.Relname
+		if len(matchTables) > 0 && !matchTables[tableName] {
+			continue
+		}
+
+		tbl := Table{Name: tableName}
+		if s.Relation.Schemaname != nil {
+			tbl.Schema = *s.Relation.Schemaname
+		}
+		for _, col := range s.TableElts.Items {
+			switch col := col.(type) {
+			case nodes.ColumnDef:
+				notNull := isNotNull(col.Constraints.Items)
+				info := f.TypeResolver.Resolve(col.TypeName.Names.Items, notNull)
+				tbl.Columns = append(tbl.Columns,
+					Column{
+						Name:    *col.Colname,
+						Type:    toString(col.TypeName.Names.Items),
+						GoType:  info.GoType,
+						Import:  info.Import,
+						NotNull: notNull,
+					})
+				for _, cc := range col.Constraints.Items {
+					if con, ok := cc.(nodes.Constraint); ok {
+						applyConstraint(&tbl, con, *col.Colname)
+					}
+				}
+			case nodes.Constraint:
+				applyConstraint(&tbl, col, "")
+			}
+		}
+		tables = append(tables, tbl)
+	}
+
+	// a second pass folds ALTER TABLE and CREATE INDEX statements into the
+	// tables already collected above, so a schema that evolves via
+	// migrations still produces one coherent model per table.
+	for _, a := range alterTables {
+		tableName := *a.Relation.Relname
+		for i := range tables {
+			if tables[i].Name == tableName {
+				applyAlterTable(&tables[i], a, f.TypeResolver)
+				break
+			}
+		}
+	}
+	for _, idx := range createIndexes {
+		tableName := *idx.Relation.Relname
+		for i := range tables {
+			if tables[i].Name == tableName {
+				applyIndex(&tables[i], idx)
+				break
+			}
+		}
+	}
+
+	return tables, nil
+}
+
+// stmtText slices a RawStmt's original text out of the source it was
+// parsed from.
+func stmtText(src string, raw nodes.RawStmt) string {
+	end := raw.StmtLocation + raw.StmtLen
+	if raw.StmtLen == 0 {
+		end = len(src)
+	}
+	return strings.TrimSpace(src[raw.StmtLocation:end])
+}
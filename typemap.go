@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	nodes "github.com/lfittl/pg_query_go/nodes"
+)
+
+// TypeInfo describes how a PostgreSQL column type maps onto Go, for both the
+// plain and nullable (NULL-allowed) forms of a column.
+type TypeInfo struct {
+	GoType   string `json:"goType"`
+	Nullable string `json:"nullable"`
+	Import   string `json:"import"`
+}
+
+// defaultTypeMap maps common PostgreSQL type names to their Go equivalents,
+// following the same families sqlboiler and gorm/gen use: numeric, text,
+// date/time, uuid, bytea and the serial variants.
+var defaultTypeMap = map[string]TypeInfo{
+	"int2":        {GoType: "int16", Nullable: "sql.NullInt32", Import: "database/sql"},
+	"int4":        {GoType: "int32", Nullable: "sql.NullInt32", Import: "database/sql"},
+	"int8":        {GoType: "int64", Nullable: "sql.NullInt64", Import: "database/sql"},
+	"serial":      {GoType: "int32", Nullable: "sql.NullInt32", Import: "database/sql"},
+	"serial4":     {GoType: "int32", Nullable: "sql.NullInt32", Import: "database/sql"},
+	"bigserial":   {GoType: "int64", Nullable: "sql.NullInt64", Import: "database/sql"},
+	"serial8":     {GoType: "int64", Nullable: "sql.NullInt64", Import: "database/sql"},
+	"float4":      {GoType: "float32", Nullable: "sql.NullFloat64", Import: "database/sql"},
+	"float8":      {GoType: "float64", Nullable: "sql.NullFloat64", Import: "database/sql"},
+	"numeric":     {GoType: "float64", Nullable: "sql.NullFloat64", Import: "database/sql"},
+	"bool":        {GoType: "bool", Nullable: "sql.NullBool", Import: "database/sql"},
+	"text":        {GoType: "string", Nullable: "null.String", Import: "gopkg.in/guregu/null.v4"},
+	"varchar":     {GoType: "string", Nullable: "null.String", Import: "gopkg.in/guregu/null.v4"},
+	"bpchar":      {GoType: "string", Nullable: "null.String", Import: "gopkg.in/guregu/null.v4"},
+	"uuid":        {GoType: "uuid.UUID", Nullable: "uuid.NullUUID", Import: "github.com/google/uuid"},
+	"timestamp":   {GoType: "time.Time", Nullable: "null.Time", Import: "time"},
+	"timestamptz": {GoType: "time.Time", Nullable: "null.Time", Import: "time"},
+	"date":        {GoType: "time.Time", Nullable: "null.Time", Import: "time"},
+	"bytea":       {GoType: "[]byte", Nullable: "[]byte", Import: ""},
+	"json":        {GoType: "[]byte", Nullable: "[]byte", Import: ""},
+	"jsonb":       {GoType: "[]byte", Nullable: "[]byte", Import: ""},
+}
+
+// TypeResolver resolves PostgreSQL column types to Go types, consulting
+// user-supplied overrides before falling back to defaultTypeMap.
+type TypeResolver struct {
+	overrides map[string]TypeInfo
+}
+
+// NewTypeResolver builds a TypeResolver. If configFile is non-empty, it is
+// read as a JSON object of PostgreSQL type name to TypeInfo and takes
+// precedence over defaultTypeMap entries of the same name.
+func NewTypeResolver(configFile string) (*TypeResolver, error) {
+	r := &TypeResolver{overrides: map[string]TypeInfo{}}
+	if configFile == "" {
+		return r, nil
+	}
+	d, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read type config %s: %s", configFile, err)
+	}
+	if err := json.Unmarshal(d, &r.overrides); err != nil {
+		return nil, fmt.Errorf("unable to parse type config %s: %s", configFile, err)
+	}
+	return r, nil
+}
+
+// Resolve returns the Go type for a column whose PostgreSQL type is given by
+// typeNames, choosing the nullable form unless notNull is set.
+func (r *TypeResolver) Resolve(typeNames []nodes.Node, notNull bool) TypeInfo {
+	return r.ResolveName(strings.ToLower(toString(typeNames)), notNull)
+}
+
+// infoSchemaTypeAliases maps the verbose type names Postgres reports via
+// information_schema.columns.data_type onto the short names used as keys in
+// defaultTypeMap, so DBSource can share the same resolver as FileSource.
+var infoSchemaTypeAliases = map[string]string{
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"integer":                     "int4",
+	"smallint":                    "int2",
+	"bigint":                      "int8",
+	"boolean":                     "bool",
+	"double precision":            "float8",
+	"real":                        "float4",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+}
+
+// ResolveName returns the Go type for a column given its raw PostgreSQL type
+// name, choosing the nullable form unless notNull is set. pgType may be
+// either a parser-internal name (e.g. "int4", optionally "pg_catalog"
+// qualified the way the parser normalizes SQL-standard spellings like
+// "integer" or "boolean") or an information_schema data_type (e.g.
+// "integer").
+func (r *TypeResolver) ResolveName(pgType string, notNull bool) TypeInfo {
+	pgType = strings.ToLower(pgType)
+	pgType = strings.TrimPrefix(pgType, "pg_catalog ")
+	if alias, ok := infoSchemaTypeAliases[pgType]; ok {
+		pgType = alias
+	}
+	info, isOverride := r.overrides[pgType]
+	if !isOverride {
+		var ok bool
+		info, ok = defaultTypeMap[pgType]
+		if !ok {
+			log.Printf("no type mapping for %q, defaulting to string (null.String when nullable)", pgType)
+			info = TypeInfo{GoType: "string", Nullable: "null.String", Import: "gopkg.in/guregu/null.v4"}
+		}
+	}
+	if notNull {
+		// defaultTypeMap's Import describes the package the Nullable form
+		// needs (sql.Null*, null.*), not the bare GoType - time.Time and
+		// uuid.UUID are the exceptions, and scanGoType recognizes those by
+		// name directly. A -type-config override has no such built-in
+		// handling, so its Import applies to whichever form is returned.
+		if isOverride {
+			return TypeInfo{GoType: info.GoType, Import: info.Import}
+		}
+		return TypeInfo{GoType: info.GoType}
+	}
+	return TypeInfo{GoType: info.Nullable, Import: info.Import}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestResolveNameStripsPgCatalog covers the round trip that bit us: the
+// parser normalizes SQL-standard-spelled types like "boolean" and "integer"
+// into their pg_catalog-qualified internal names before ResolveName ever
+// sees them.
+func TestResolveNameStripsPgCatalog(t *testing.T) {
+	r, err := NewTypeResolver("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		pgType string
+		goType string
+	}{
+		{"pg_catalog bool", "bool"},
+		{"pg_catalog int4", "int32"},
+		{"pg_catalog numeric", "float64"},
+		{"bool", "bool"},
+	}
+	for _, c := range cases {
+		info := r.ResolveName(c.pgType, true)
+		if info.GoType != c.goType {
+			t.Errorf("ResolveName(%q, true) = %q, want %q", c.pgType, info.GoType, c.goType)
+		}
+	}
+}
+
+// TestResolveNameOverrideImportSurvivesNotNull covers a -type-config
+// override's Import on a NOT NULL column: ResolveName used to drop it on
+// that branch, which silently produced a model field referencing a package
+// that was never imported.
+func TestResolveNameOverrideImportSurvivesNotNull(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "types.json")
+	cfg := `{"money_amount": {"goType": "money.Amount", "import": "github.com/acme/money"}}`
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewTypeResolver(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := r.ResolveName("money_amount", true)
+	if info.GoType != "money.Amount" || info.Import != "github.com/acme/money" {
+		t.Fatalf("ResolveName(\"money_amount\", true) = %+v, want GoType money.Amount, Import github.com/acme/money", info)
+	}
+
+	// a built-in NOT NULL type must not pick up its Nullable form's import.
+	if info := r.ResolveName("bool", true); info.Import != "" {
+		t.Fatalf("ResolveName(\"bool\", true).Import = %q, want empty", info.Import)
+	}
+}